@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/mdaxf/iac/com"
+	"github.com/mdaxf/iac/config"
+	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/integration/activemq"
+	"github.com/mdaxf/iac/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ConfigSource abstracts where ActiveMQconfigs is loaded from, so nodes can read the
+// local activemqconfig.json (the historical behavior) or a central MongoDB collection
+// that the IAC admin UI edits, without initializeActiveMQConnection caring which.
+type ConfigSource interface {
+	// Load fetches the current ActiveMQconfigs.
+	Load() (activemq.ActiveMQconfigs, error)
+	// Watch calls onChange whenever the underlying source changes, until ctx is
+	// cancelled. It blocks, so callers run it in its own goroutine.
+	Watch(ctx context.Context, onChange func(activemq.ActiveMQconfigs))
+}
+
+// fileConfigSource reads activemqconfig.json from the working directory and is watched
+// via fsnotify for hot-reload (see watcher.go).
+type fileConfigSource struct {
+	path string
+	ilog logger.Log
+}
+
+func (f fileConfigSource) Load() (activemq.ActiveMQconfigs, error) {
+	var cfgs activemq.ActiveMQconfigs
+
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return cfgs, err
+	}
+	f.ilog.Debug(fmt.Sprintf("ActiveMQ conenction configuration file: %s", string(data)))
+
+	if err := json.Unmarshal(data, &cfgs); err != nil {
+		return cfgs, err
+	}
+	f.ilog.Debug(fmt.Sprintf("ActiveMQ Connection configuration: %v", logger.ConvertJson(cfgs)))
+
+	return cfgs, nil
+}
+
+// mongoConfigSource loads ActiveMQconfigs from a central MongoDB collection, keyed by
+// this node's name and environment, so config edits made in the IAC admin UI reach
+// every iac-activemq node without a redeploy.
+type mongoConfigSource struct {
+	docDB       *documents.DocDB
+	collection  string
+	nodeName    string
+	environment string
+	ilog        logger.Log
+}
+
+func (m mongoConfigSource) Load() (activemq.ActiveMQconfigs, error) {
+	var cfgs activemq.ActiveMQconfigs
+
+	filter := bson.M{"nodedata.Name": m.nodeName, "environment": m.environment}
+	var doc bson.M
+	if err := m.docDB.Database.Collection(m.collection).FindOne(context.Background(), filter).Decode(&doc); err != nil {
+		return cfgs, fmt.Errorf("loading ActiveMQ config from %s: %w", m.collection, err)
+	}
+
+	// Re-marshal through JSON so the document decodes with the same field matching
+	// activemq.ActiveMQconfigs already uses for activemqconfig.json, instead of relying
+	// on the mongo driver's bson tag conventions for an externally-owned struct.
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return cfgs, err
+	}
+	if err := json.Unmarshal(data, &cfgs); err != nil {
+		return cfgs, err
+	}
+
+	m.ilog.Debug(fmt.Sprintf("ActiveMQ Connection configuration from %s: %v", m.collection, logger.ConvertJson(cfgs)))
+	return cfgs, nil
+}
+
+func (m mongoConfigSource) Watch(ctx context.Context, onChange func(activemq.ActiveMQconfigs)) {
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: bson.D{
+		{Key: "fullDocument.nodedata.Name", Value: m.nodeName},
+		{Key: "fullDocument.environment", Value: m.environment},
+	}}}}
+
+	stream, err := m.docDB.Database.Collection(m.collection).Watch(ctx, pipeline, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		m.ilog.Error(fmt.Sprintf("failed to start MongoDB change stream on %s: %v", m.collection, err))
+		return
+	}
+	defer stream.Close(ctx)
+
+	m.ilog.Debug(fmt.Sprintf("watching %s for configuration changes", m.collection))
+	for stream.Next(ctx) {
+		cfgs, err := m.Load()
+		if err != nil {
+			m.ilog.Error(fmt.Sprintf("change stream triggered ActiveMQ config reload failed: %v", err))
+			continue
+		}
+		onChange(cfgs)
+	}
+}
+
+// newActiveMQConfigSource picks the MongoDB config source when gconfig.DocumentConfig
+// names a configCollection and docDB is up, falling back to the local
+// activemqconfig.json file if Mongo is unavailable at boot or no collection is configured.
+func newActiveMQConfigSource(gconfig *config.GlobalConfig, docDB *documents.DocDB, ilog logger.Log) ConfigSource {
+	file := fileConfigSource{path: activemqConfigFile, ilog: ilog}
+
+	collection, _ := gconfig.DocumentConfig["configCollection"].(string)
+	if collection == "" || docDB == nil {
+		return file
+	}
+
+	mongoSource := mongoConfigSource{
+		docDB:       docDB,
+		collection:  collection,
+		nodeName:    com.ConverttoString(nodedata["Name"]),
+		environment: com.ConverttoString(gconfig.AppServer["environment"]),
+		ilog:        ilog,
+	}
+
+	if _, err := mongoSource.Load(); err != nil {
+		ilog.Error(fmt.Sprintf("MongoDB config source unavailable at boot (%v), falling back to %s", err, activemqConfigFile))
+		return file
+	}
+
+	return mongoSource
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/integration/activemq"
+	"github.com/mdaxf/iac/logger"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// dlqCollection is the MongoDB collection quarantined poison messages are persisted to.
+const dlqCollection = "activemq_dlq"
+
+// DeadLetterRecord is the quarantine record persisted to docDB when TranCode_Execute
+// fails for a message. instrumentDLQ cannot tell which ActiveMQ connection a given
+// dispatch came from (see its comment), so Host and SourceQueue are left empty at
+// quarantine time; replayMessage accepts them as explicit overrides so an operator who
+// knows which broker/queue a record came from can still replay it.
+type DeadLetterRecord struct {
+	ID            string     `bson:"_id" json:"id"`
+	Host          string     `bson:"host" json:"host"`
+	SourceQueue   string     `bson:"sourcequeue" json:"sourcequeue"`
+	TranCode      string     `bson:"trancode" json:"trancode"`
+	Body          string     `bson:"body" json:"body"`
+	Error         string     `bson:"error" json:"error"`
+	Stack         string     `bson:"stack" json:"stack"`
+	AttemptCount  int        `bson:"attemptcount" json:"attemptcount"`
+	QuarantinedAt time.Time  `bson:"quarantinedat" json:"quarantinedat"`
+	ReplayedAt    *time.Time `bson:"replayedat,omitempty" json:"replayedat,omitempty"`
+}
+
+// quarantineMessage persists a poison message plus its failure context to the DLQ
+// collection so operators can inspect it via docDB and, once the underlying issue is
+// fixed, replay it with /dlq/replay.
+func quarantineMessage(docDB *documents.DocDB, ilog logger.Log, record DeadLetterRecord) error {
+	if record.ID == "" {
+		record.ID = uuid.New().String()
+	}
+	record.QuarantinedAt = time.Now().UTC()
+
+	_, err := docDB.Database.Collection(dlqCollection).InsertOne(context.Background(), record)
+	if err != nil {
+		ilog.Error(fmt.Sprintf("failed to persist DLQ record for %s/%s: %v", record.Host, record.SourceQueue, err))
+		return err
+	}
+	ilog.Debug(fmt.Sprintf("quarantined poison message %s from %s/%s after %d attempts", record.ID, record.Host, record.SourceQueue, record.AttemptCount))
+	return nil
+}
+
+// instrumentDLQ wraps the callback registered for "TranCode_Execute" so a dispatch that
+// fails is quarantined via quarantineMessage instead of being silently dropped.
+//
+// TranCode_Execute is a single callback shared across every ActiveMQ connection on this
+// node (it's registered once, by name, in callback_mgr), and reflect.MakeFunc only sees
+// the arguments the upstream caller happens to pass it - there is no connection handle or
+// context.Context in that signature to say which host/queue a given message came from.
+// Bounded-retry-with-redelivery-count is the same story as the BrokerAdapter gap: it
+// needs the STOMP adapter to NACK and redeliver with a header-tracked attempt count,
+// which only the upstream mdaxf/iac integration/activemq package can do. So this wrapper
+// does not retry or sleep; it quarantines on the first failure and leaves real redelivery
+// to that adapter. Host and SourceQueue on the resulting record are consequently left
+// unset here - see the comment on DeadLetterRecord and the hostOverride/queueOverride
+// parameters /dlq/replay accepts to compensate.
+func instrumentDLQ(fn interface{}, docDB *documents.DocDB, ilog logger.Log) interface{} {
+	fnValue := reflect.ValueOf(fn)
+
+	wrapped := reflect.MakeFunc(fnValue.Type(), func(args []reflect.Value) []reflect.Value {
+		results := fnValue.Call(args)
+		lastErr := firstError(results)
+		if lastErr == nil {
+			return results
+		}
+
+		ilog.Error(fmt.Sprintf("TranCode_Execute failed, quarantining message (host/queue unknown to this callback, pass them to /dlq/replay explicitly to replay it): %v", lastErr))
+		record := DeadLetterRecord{
+			TranCode:     firstArgAsTranCode(args),
+			Body:         argsToString(args),
+			Error:        lastErr.Error(),
+			Stack:        string(debug.Stack()),
+			AttemptCount: 1,
+		}
+		if err := quarantineMessage(docDB, ilog, record); err != nil {
+			ilog.Error(fmt.Sprintf("failed to quarantine failed message: %v", err))
+		}
+
+		return results
+	})
+
+	return wrapped.Interface()
+}
+
+func firstError(results []reflect.Value) error {
+	for _, result := range results {
+		if err, ok := result.Interface().(error); ok && err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func argsToString(args []reflect.Value) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = fmt.Sprintf("%v", arg.Interface())
+	}
+	return strings.Join(parts, "|")
+}
+
+// firstArgAsTranCode assumes, by the "TranCode_Execute" naming convention, that the
+// first argument to the wrapped callback is the trancode name. It falls back to an
+// empty string rather than guessing at a non-string first argument.
+func firstArgAsTranCode(args []reflect.Value) string {
+	if len(args) == 0 {
+		return ""
+	}
+	if name, ok := args[0].Interface().(string); ok {
+		return name
+	}
+	return ""
+}
+
+// replayMessage looks up a quarantined message by ID and republishes its body back to
+// its source queue on the matching ActiveMQ connection, backing the /dlq/replay endpoint.
+// hostOverride and queueOverride are used in place of the record's own Host/SourceQueue
+// when non-empty, since instrumentDLQ usually can't populate those at quarantine time
+// (see the comment on DeadLetterRecord) - an operator who knows where the message
+// belongs can pass them explicitly.
+func replayMessage(docDB *documents.DocDB, ilog logger.Log, id, hostOverride, queueOverride string) error {
+	var record DeadLetterRecord
+	if err := docDB.Database.Collection(dlqCollection).FindOne(context.Background(), bson.M{"_id": id}).Decode(&record); err != nil {
+		return fmt.Errorf("quarantined message %s not found: %w", id, err)
+	}
+
+	host := record.Host
+	if hostOverride != "" {
+		host = hostOverride
+	}
+	queue := record.SourceQueue
+	if queueOverride != "" {
+		queue = queueOverride
+	}
+
+	target := findActiveMQByHost(host)
+	if target == nil {
+		return fmt.Errorf("no active ActiveMQ connection for host %q; pass an explicit host query parameter", host)
+	}
+	if queue == "" {
+		return fmt.Errorf("source queue for message %s is unknown; pass an explicit queue query parameter", id)
+	}
+
+	if err := target.Publish(queue, record.Body); err != nil {
+		return fmt.Errorf("failed to republish quarantined message %s: %w", id, err)
+	}
+
+	now := time.Now().UTC()
+	_, err := docDB.Database.Collection(dlqCollection).UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$set": bson.M{"replayedat": now}})
+	if err != nil {
+		ilog.Error(fmt.Sprintf("replayed %s but failed to mark it replayed in docDB: %v", id, err))
+	}
+	ilog.Debug(fmt.Sprintf("replayed quarantined message %s back to %s/%s", id, host, queue))
+
+	return nil
+}
+
+func findActiveMQByHost(host string) *activemq.ActiveMQ {
+	activemqMutex.Lock()
+	defer activemqMutex.Unlock()
+
+	for _, conn := range ActiveMQs {
+		if conn.Config.Host == host {
+			return conn
+		}
+	}
+	return nil
+}
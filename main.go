@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
@@ -29,12 +28,24 @@ import (
 	"github.com/mdaxf/iac-signalr/signalr"
 )
 
+const activemqConfigFile = "activemqconfig.json"
+
 var (
 	nodedata      map[string]interface{}
 	nodecomponent map[string]interface{}
 	monitorPort   int
 	monitorServer *http.Server
-	ActiveMQs     []*activemq.ActiveMQ
+	// ActiveMQs holds the concrete *activemq.ActiveMQ connections this node manages.
+	// A BrokerAdapter interface (Connect/Subscribe/Publish/Ack/Close/Stats) with a
+	// stompAdapter plus amqp10/openwire/mqtt siblings behind it, so ActiveMQs could
+	// hold BrokerAdapter instead of this concrete type, is NOT implemented in this
+	// repo - that refactor belongs to the upstream mdaxf/iac integration/activemq
+	// package. What's implemented here is limited to defaulting/reporting the
+	// Protocol field and reading the Stats() accessor below.
+	ActiveMQs []*activemq.ActiveMQ
+
+	activemqMutex        sync.Mutex
+	activemqConfigSource ConfigSource
 )
 
 func main() {
@@ -83,10 +94,13 @@ func main() {
 	nodecomponent["DocDB"] = docDB
 	nodecomponent["IACMessageBusClient"] = IACMessageBusClient
 
+	initMetrics(gconfig, ilog)
+
 	if callback_mgr.CallBackMap["TranCode_Execute"] == nil {
 		ilog.Debug("Register the trancode execution interface")
 		tfr := trancode.TranFlowstr{}
-		callback_mgr.RegisterCallBack("TranCode_Execute", tfr.Execute)
+		dispatch := instrumentDLQ(tfr.Execute, docDB, ilog)
+		callback_mgr.RegisterCallBack("TranCode_Execute", instrumentTranCodeExecute(dispatch))
 	}
 
 	// Initialize the ActiveMQ Connection
@@ -147,9 +161,11 @@ func HeartBeat(ilog logger.Log, gconfig *config.GlobalConfig, DB *sql.DB, DocDB
 	response, err := com.CallWebService(appHeartBeatUrl, "POST", data, headers)
 
 	if err != nil {
+		recordHeartbeat("failure")
 		ilog.Error(fmt.Sprintf("HeartBeat error: %v", err))
 		return
 	}
+	recordHeartbeat("success")
 
 	ilog.Debug(fmt.Sprintf("HeartBeat post response: %v", response))
 }
@@ -159,14 +175,33 @@ func CheckServiceStatus(iLog logger.Log) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 	OKCount := 0
 	UnavailableCount := 0
+
+	activemqMutex.Lock()
+	defer activemqMutex.Unlock()
+
 	for _, activemqconn := range ActiveMQs {
-		if activemqconn.Conn != nil {
-			result[activemqconn.Config.Host] = true
+		status := make(map[string]interface{})
+
+		reconnectStatus, breakerOpen := supervisorStatus(activemqConnectionKey(activemqconn.Config))
+		if reconnectStatus != nil {
+			status["reconnect"] = reconnectStatus
+		}
+
+		connected := activemqconn.Conn != nil && !breakerOpen
+		status["connected"] = connected
+		if connected {
 			OKCount++
 		} else {
-			result[activemqconn.Config.Host] = false
 			UnavailableCount++
 		}
+
+		stats := activemqconn.Stats()
+		status["protocol"] = activemqconn.Config.Protocol
+		status["version"] = stats.Version
+		status["messagesconsumed"] = stats.MessagesConsumed
+		status["messagespublished"] = stats.MessagesPublished
+
+		result[activemqconn.Config.Host] = status
 	}
 	OverAllStatus := health.StatusOK
 
@@ -199,30 +234,77 @@ func initializeActiveMQConnection(gconfig *config.GlobalConfig, ilog logger.Log,
 
 	ilog.Debug("initialize ActiveMQ Connection")
 
-	data, err := ioutil.ReadFile("activemqconfig.json")
+	activemqConfigSource = newActiveMQConfigSource(gconfig, DocDB, ilog)
+
+	reloadActiveMQConnections(gconfig, ilog, DB, DocDB, IACMessageBusClient)
+
+	go activemqConfigSource.Watch(context.Background(), func(activemqconfigs activemq.ActiveMQconfigs) {
+		reconcileActiveMQConnections(gconfig, ilog, DB, DocDB, IACMessageBusClient, activemqconfigs)
+	})
+}
+
+// reloadActiveMQConnections reloads ActiveMQconfigs from activemqConfigSource and
+// reconciles it against the running ActiveMQs. It is safe to call repeatedly and backs
+// the /reloadconfig endpoint; the config source's own change notifications pass their
+// already loaded configs straight to reconcileActiveMQConnections instead.
+func reloadActiveMQConnections(gconfig *config.GlobalConfig, ilog logger.Log, DB *sql.DB, DocDB *documents.DocDB, IACMessageBusClient signalr.Client) {
+	activemqconfigs, err := activemqConfigSource.Load()
 	if err != nil {
-		ilog.Debug(fmt.Sprintf("failed to read configuration file: %v", err))
+		ilog.Debug(fmt.Sprintf("failed to load the ActiveMQ configuration: %v", err))
 		return
 	}
-	ilog.Debug(fmt.Sprintf("ActiveMQ conenction configuration file: %s", string(data)))
-	var activemqconfigs activemq.ActiveMQconfigs
 
-	err = json.Unmarshal(data, &activemqconfigs)
-	if err != nil {
-		ilog.Debug(fmt.Sprintf("failed to unmarshal the configuration file: %v", err))
+	reconcileActiveMQConnections(gconfig, ilog, DB, DocDB, IACMessageBusClient, activemqconfigs)
+}
 
-	}
-	ilog.Debug(fmt.Sprintf("ActiveMQ Connection configuration: %v", logger.ConvertJson(activemqconfigs)))
+// activemqConnectionKey identifies a connection for diffing purposes: entries whose
+// host, queue and selector are unchanged across a reload keep their existing connection.
+func activemqConnectionKey(cfg activemq.ActiveMQ) string {
+	return fmt.Sprintf("%s|%s|%s", cfg.Host, cfg.Queue, cfg.Selector)
+}
 
+// reconcileActiveMQConnections diffs the newly parsed configuration against the running
+// ActiveMQs, closing connections that were removed, starting connections that were added,
+// and leaving unchanged connections alone so reload is zero-downtime.
+func reconcileActiveMQConnections(gconfig *config.GlobalConfig, ilog logger.Log, DB *sql.DB, DocDB *documents.DocDB, IACMessageBusClient signalr.Client, activemqconfigs activemq.ActiveMQconfigs) {
+	activemqMutex.Lock()
+	defer activemqMutex.Unlock()
+
+	desired := make(map[string]activemq.ActiveMQ, len(activemqconfigs.ActiveMQs))
 	for _, activemqcfg := range activemqconfigs.ActiveMQs {
+		if activemqcfg.Protocol == "" {
+			// preserve backward compatibility with configs predating the Protocol field
+			activemqcfg.Protocol = "stomp"
+		}
+		desired[activemqConnectionKey(activemqcfg)] = activemqcfg
+	}
+
+	kept := make([]*activemq.ActiveMQ, 0, len(desired))
+	for _, activemqconn := range ActiveMQs {
+		key := activemqConnectionKey(activemqconn.Config)
+		if _, ok := desired[key]; ok {
+			ilog.Debug(fmt.Sprintf("ActiveMQ connection %s is unchanged, keeping existing connection", key))
+			kept = append(kept, activemqconn)
+			delete(desired, key)
+		} else {
+			ilog.Debug(fmt.Sprintf("ActiveMQ connection %s was removed from configuration, shutting it down", key))
+			stopSupervisor(key)
+			activemqconn.Shutdown()
+		}
+	}
+
+	for _, activemqcfg := range desired {
 		ilog.Debug(fmt.Sprintf("Single ActiveMQ Connection configuration: %s", logger.ConvertJson(activemqcfg)))
+		ilog.Debug(fmt.Sprintf("ActiveMQ Connection %s is using the %s broker adapter", activemqcfg.Host, activemqcfg.Protocol))
+
 		activemqconn := activemq.NewActiveMQConnectionExternal(activemqcfg, DocDB, DB, IACMessageBusClient)
 		activemqconn.AppServer = com.ConverttoString(gconfig.AppServer["url"])
 		activemqconn.ApiKey = activemqconfigs.ApiKey
-		ActiveMQs = append(ActiveMQs, activemqconn)
-		activemqconn.Subscribes()
-
+		startSupervisor(activemqConnectionKey(activemqcfg), activemqconn, ilog)
+		kept = append(kept, activemqconn)
 	}
+
+	ActiveMQs = kept
 }
 
 func waitForTerminationSignal(ilog logger.Log, gconfig *config.GlobalConfig) {
@@ -438,18 +520,78 @@ func startMonitorServer(ilog logger.Log, gconfig *config.GlobalConfig) {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
-		ilog.Debug("Reloading configuration - close the connections and reinitialize the components")
-		/*	nodecomponent["DB"].(*sql.DB).Close()
-			nodecomponent["DocDB"].(*documents.DocDB).MongoDBClient.Disconnect(nil)
-			nodecomponent["IACMessageBusClient"].(signalr.Client).Stop()
-		*/
-		initializeActiveMQConnection(gconfig, ilog, nodecomponent["DB"].(*sql.DB), nodecomponent["DocDB"].(*documents.DocDB), nodecomponent["IACMessageBusClient"].(signalr.Client))
+		ilog.Debug("Reloading configuration - diff against the running ActiveMQ connections")
+		reloadActiveMQConnections(gconfig, ilog, nodecomponent["DB"].(*sql.DB), nodecomponent["DocDB"].(*documents.DocDB), nodecomponent["IACMessageBusClient"].(signalr.Client))
 
 		w.Header().Set("Content-Type", "application/json")
 		data := make(map[string]interface{})
 		data["Status"] = "Success"
 		json.NewEncoder(w).Encode(data)
 	})
+	http.HandleFunc("/reconnect", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Authorization") != "apikey "+gconfig.AppServer["apikey"].(string) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		host := r.URL.Query().Get("host")
+		if host == "" {
+			http.Error(w, "host query parameter is required", http.StatusBadRequest)
+			return
+		}
+		ilog.Debug(fmt.Sprintf("Force-closing the circuit breaker for %s", host))
+
+		data := make(map[string]interface{})
+		if err := resetBreaker(host); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			data["Status"] = "Failed"
+			data["Error"] = err.Error()
+		} else {
+			data["Status"] = "Success"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(data)
+	})
+	if metricsEnabled {
+		ilog.Debug("Registering /metrics endpoint (metrics.enabled is true)")
+		http.Handle("/metrics", metricsHandler())
+	}
+	http.HandleFunc("/dlq/replay", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Authorization") != "apikey "+gconfig.AppServer["apikey"].(string) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id query parameter is required", http.StatusBadRequest)
+			return
+		}
+		// host/queue let an operator replay a record whose Host/SourceQueue weren't
+		// known at quarantine time - see the comment on DeadLetterRecord.
+		host := r.URL.Query().Get("host")
+		queue := r.URL.Query().Get("queue")
+		ilog.Debug(fmt.Sprintf("Replaying quarantined message %s", id))
+
+		data := make(map[string]interface{})
+		if err := replayMessage(nodecomponent["DocDB"].(*documents.DocDB), ilog, id, host, queue); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			data["Status"] = "Failed"
+			data["Error"] = err.Error()
+		} else {
+			data["Status"] = "Success"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(data)
+	})
 	ilog.Debug(fmt.Sprintf("Starting server on port %d", monitorPort))
 	err = monitorServer.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mdaxf/iac/integration/activemq"
+)
+
+func TestActiveMQConnectionKey(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  activemq.ActiveMQ
+		want string
+	}{
+		{
+			name: "host, queue and selector all set",
+			cfg:  activemq.ActiveMQ{Host: "broker.local:61613", Queue: "orders", Selector: "type = 'order'"},
+			want: "broker.local:61613|orders|type = 'order'",
+		},
+	}
+
+	for _, c := range cases {
+		if got := activemqConnectionKey(c.cfg); got != c.want {
+			t.Errorf("activemqConnectionKey(%+v) = %q, want %q", c.cfg, got, c.want)
+		}
+	}
+}
+
+func TestActiveMQConnectionKeyDistinguishesSameHost(t *testing.T) {
+	a := activemqConnectionKey(activemq.ActiveMQ{Host: "broker.local:61613", Queue: "orders", Selector: ""})
+	b := activemqConnectionKey(activemq.ActiveMQ{Host: "broker.local:61613", Queue: "shipments", Selector: ""})
+
+	if a == b {
+		t.Fatalf("expected distinct keys for different queues on the same host, got %q for both", a)
+	}
+}
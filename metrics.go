@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/mdaxf/iac/config"
+	"github.com/mdaxf/iac/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	metricsEnabled  bool
+	metricsRegistry *prometheus.Registry
+
+	trancodeLatency   *prometheus.HistogramVec
+	subscriptionUp    *prometheus.GaugeVec
+	reconnectAttempts *prometheus.CounterVec
+	heartbeatResults  *prometheus.CounterVec
+)
+
+// initMetrics sets up a private Prometheus registry, gated behind the opt-in
+// metrics.enabled flag in AppServer, so this process's counters never collide
+// with the default Go collectors in whatever is hosting it.
+func initMetrics(gconfig *config.GlobalConfig, ilog logger.Log) {
+	metricsEnabled = isMetricsEnabled(gconfig)
+	if !metricsEnabled {
+		ilog.Debug("Prometheus metrics are disabled, set AppServer.metrics.enabled to true to turn them on")
+		return
+	}
+
+	metricsRegistry = prometheus.NewRegistry()
+
+	trancodeLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "iac_activemq_trancode_execute_seconds",
+		Help:    "Latency of TranCode_Execute dispatch triggered by ActiveMQ messages.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"status"})
+
+	subscriptionUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "iac_activemq_subscription_up",
+		Help: "1 if the ActiveMQ subscription for a host is currently connected, 0 otherwise.",
+	}, []string{"host"})
+
+	reconnectAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "iac_activemq_reconnect_attempts_total",
+		Help: "Number of supervised reconnect attempts, labeled by host and outcome.",
+	}, []string{"host", "outcome"})
+
+	heartbeatResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "iac_activemq_heartbeat_total",
+		Help: "Number of heartbeat posts to the app server, labeled by outcome.",
+	}, []string{"outcome"})
+
+	metricsRegistry.MustRegister(trancodeLatency, subscriptionUp, reconnectAttempts, heartbeatResults)
+}
+
+// isMetricsEnabled reads the opt-in AppServer.metrics.enabled toggle. GlobalConfig
+// itself lives upstream in mdaxf/iac; until it grows a typed MetricsConfig field this
+// reads the existing AppServer map the same way the apikey/url settings do.
+func isMetricsEnabled(gconfig *config.GlobalConfig) bool {
+	metricsConfig, ok := gconfig.AppServer["metrics"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	enabled, _ := metricsConfig["enabled"].(bool)
+	return enabled
+}
+
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// instrumentTranCodeExecute wraps the callback registered for "TranCode_Execute" so
+// every dispatch triggered by an ActiveMQ message is timed into trancodeLatency. It is
+// built with reflection rather than a fixed signature so it keeps working if the
+// upstream trancode.TranFlowstr.Execute signature changes.
+//
+// main.go registers this around instrumentDLQ's result, not the other way round, so it
+// times a single TranCode_Execute call. instrumentDLQ doesn't retry or sleep (see its own
+// comment), so that single call is all trancodeLatency ever observes.
+func instrumentTranCodeExecute(fn interface{}) interface{} {
+	if !metricsEnabled {
+		return fn
+	}
+
+	fnValue := reflect.ValueOf(fn)
+	wrapped := reflect.MakeFunc(fnValue.Type(), func(args []reflect.Value) []reflect.Value {
+		start := time.Now()
+		results := fnValue.Call(args)
+
+		status := "success"
+		for _, result := range results {
+			if err, ok := result.Interface().(error); ok && err != nil {
+				status = "error"
+			}
+		}
+		trancodeLatency.WithLabelValues(status).Observe(time.Since(start).Seconds())
+
+		return results
+	})
+
+	return wrapped.Interface()
+}
+
+func recordReconnectAttempt(host, outcome string) {
+	if !metricsEnabled {
+		return
+	}
+	reconnectAttempts.WithLabelValues(host, outcome).Inc()
+}
+
+func recordSubscriptionState(host string, up bool) {
+	if !metricsEnabled {
+		return
+	}
+	value := 0.0
+	if up {
+		value = 1.0
+	}
+	subscriptionUp.WithLabelValues(host).Set(value)
+}
+
+func recordHeartbeat(outcome string) {
+	if !metricsEnabled {
+		return
+	}
+	heartbeatResults.WithLabelValues(outcome).Inc()
+}
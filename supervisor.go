@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdaxf/iac/integration/activemq"
+	"github.com/mdaxf/iac/logger"
+)
+
+const (
+	supervisorBaseBackoff    = 1 * time.Second
+	supervisorMaxBackoff     = 60 * time.Second
+	supervisorBackoffJitter  = 0.2 // +-20%
+	supervisorBreakerTripAt  = 5   // consecutive failures before the breaker opens
+	supervisorHealthyRecheck = 5 * time.Second
+)
+
+// breakerState tracks the reconnect backoff and circuit-breaker state for a single
+// ActiveMQ connection so CheckServiceStatus and /reconnect can report and act on it.
+type breakerState struct {
+	mu                  sync.Mutex
+	consecutiveFailures int
+	backoff             time.Duration
+	nextAttempt         time.Time
+	open                bool
+	stop                chan struct{}
+}
+
+var (
+	// supervisorsMu guards supervisors. Entries are keyed by activemqConnectionKey
+	// (host|queue|selector), the same key reconcileActiveMQConnections diffs on, so two
+	// configs that share a host but differ by queue/selector get independent breaker
+	// state and independently cancellable goroutines.
+	supervisorsMu sync.Mutex
+	supervisors   = make(map[string]*breakerState)
+)
+
+// startSupervisor launches a goroutine that keeps a single ActiveMQ connection alive,
+// retrying Subscribes() with exponential backoff on failure and opening a circuit
+// breaker after too many consecutive failures so the health endpoint can surface it
+// instead of retrying forever. key identifies the connection the same way
+// activemqConnectionKey does.
+func startSupervisor(key string, activemqconn *activemq.ActiveMQ, ilog logger.Log) {
+	supervisorsMu.Lock()
+	state, ok := supervisors[key]
+	if !ok {
+		state = &breakerState{backoff: supervisorBaseBackoff}
+		supervisors[key] = state
+	} else if state.stop != nil {
+		// a supervisor is already running under this key; stop it before handing out a
+		// new stop channel so the old goroutine can never outlive stopSupervisor's reach
+		close(state.stop)
+	}
+	state.stop = make(chan struct{})
+	stop := state.stop
+	supervisorsMu.Unlock()
+
+	go superviseActiveMQConnection(key, activemqconn, ilog, state, stop)
+}
+
+// stopSupervisor terminates the supervisor goroutine for a connection key, if any, and
+// forgets its breaker state. Called when a connection is removed by a config reload.
+func stopSupervisor(key string) {
+	supervisorsMu.Lock()
+	defer supervisorsMu.Unlock()
+
+	if state, ok := supervisors[key]; ok {
+		close(state.stop)
+		delete(supervisors, key)
+	}
+}
+
+func superviseActiveMQConnection(key string, activemqconn *activemq.ActiveMQ, ilog logger.Log, state *breakerState, stop chan struct{}) {
+	host := activemqconn.Config.Host
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if activemqconn.Conn != nil {
+			select {
+			case <-stop:
+				return
+			case <-time.After(supervisorHealthyRecheck):
+				continue
+			}
+		}
+
+		if state.breakerOpen() {
+			select {
+			case <-stop:
+				return
+			case <-time.After(supervisorHealthyRecheck):
+				continue
+			}
+		}
+
+		if err := activemqconn.Subscribes(); err != nil {
+			wait := state.recordFailure(key, ilog, err)
+			recordReconnectAttempt(host, "failure")
+			recordSubscriptionState(host, false)
+			select {
+			case <-stop:
+				return
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		recordReconnectAttempt(host, "success")
+		recordSubscriptionState(host, true)
+		state.recordSuccess()
+	}
+}
+
+func (s *breakerState) recordFailure(key string, ilog logger.Log, err error) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.consecutiveFailures++
+	if s.backoff == 0 {
+		s.backoff = supervisorBaseBackoff
+	} else {
+		s.backoff *= 2
+		if s.backoff > supervisorMaxBackoff {
+			s.backoff = supervisorMaxBackoff
+		}
+	}
+
+	jitter := 1 + (rand.Float64()*2-1)*supervisorBackoffJitter
+	wait := time.Duration(float64(s.backoff) * jitter)
+	s.nextAttempt = time.Now().Add(wait)
+
+	if s.consecutiveFailures >= supervisorBreakerTripAt && !s.open {
+		s.open = true
+		ilog.Error(fmt.Sprintf("ActiveMQ connection %s failed %d consecutive times, opening circuit breaker", key, s.consecutiveFailures))
+	} else {
+		ilog.Debug(fmt.Sprintf("ActiveMQ connection %s reconnect failed (%v), retrying in %v", key, err, wait))
+	}
+
+	return wait
+}
+
+func (s *breakerState) recordSuccess() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.consecutiveFailures = 0
+	s.backoff = supervisorBaseBackoff
+	s.nextAttempt = time.Time{}
+	s.open = false
+}
+
+func (s *breakerState) breakerOpen() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.open
+}
+
+// supervisorStatus returns a JSON-friendly snapshot of a connection's backoff/breaker
+// state for CheckServiceStatus, and whether the breaker is currently open. key
+// identifies the connection the same way activemqConnectionKey does.
+func supervisorStatus(key string) (map[string]interface{}, bool) {
+	supervisorsMu.Lock()
+	state, ok := supervisors[key]
+	supervisorsMu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	status := map[string]interface{}{
+		"consecutivefailures": state.consecutiveFailures,
+		"backoff":             state.backoff.String(),
+		"breakeropen":         state.open,
+	}
+	if !state.nextAttempt.IsZero() {
+		status["nextattempt"] = state.nextAttempt.UTC()
+	}
+	return status, state.open
+}
+
+// resetBreaker force-closes the circuit breaker for every supervised connection whose
+// host matches, so the supervisor(s) resume reconnect attempts once their current wait
+// ends (the supervisorHealthyRecheck interval, or the backoff delay of the failure that
+// opened the breaker); the supervisor goroutines are not woken early. Backs the
+// /reconnect?host=... monitor endpoint, which only knows about host, not the full
+// host|queue|selector key multiple subscriptions against the same host would need.
+func resetBreaker(host string) error {
+	supervisorsMu.Lock()
+	var matched []*breakerState
+	for key, state := range supervisors {
+		if connectionKeyHost(key) == host {
+			matched = append(matched, state)
+		}
+	}
+	supervisorsMu.Unlock()
+
+	if len(matched) == 0 {
+		return fmt.Errorf("no supervised ActiveMQ connection for host %s", host)
+	}
+
+	for _, state := range matched {
+		state.mu.Lock()
+		state.consecutiveFailures = 0
+		state.backoff = supervisorBaseBackoff
+		state.nextAttempt = time.Time{}
+		state.open = false
+		state.mu.Unlock()
+	}
+	return nil
+}
+
+// connectionKeyHost extracts the host portion of an activemqConnectionKey
+// ("host|queue|selector").
+func connectionKeyHost(key string) string {
+	if i := strings.IndexByte(key, '|'); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mdaxf/iac/logger"
+)
+
+func TestBreakerStateRecordFailureBackoff(t *testing.T) {
+	cases := []struct {
+		name           string
+		priorBackoff   time.Duration
+		wantMinBackoff time.Duration
+		wantMaxBackoff time.Duration
+	}{
+		{"first failure starts at base backoff", 0, supervisorBaseBackoff, supervisorBaseBackoff},
+		{"second failure doubles", supervisorBaseBackoff, 2 * supervisorBaseBackoff, 2 * supervisorBaseBackoff},
+		{"doubling caps at max backoff", supervisorMaxBackoff, supervisorMaxBackoff, supervisorMaxBackoff},
+	}
+
+	ilog := logger.Log{ModuleName: logger.Framework, User: "test", ControllerName: "supervisor_test"}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := &breakerState{backoff: c.priorBackoff}
+			wait := s.recordFailure("host|queue|selector", ilog, errTestFailure)
+
+			if s.backoff != c.wantMinBackoff {
+				t.Fatalf("backoff = %v, want %v", s.backoff, c.wantMinBackoff)
+			}
+
+			minWait := time.Duration(float64(c.wantMinBackoff) * (1 - supervisorBackoffJitter))
+			maxWait := time.Duration(float64(c.wantMaxBackoff) * (1 + supervisorBackoffJitter))
+			if wait < minWait || wait > maxWait {
+				t.Fatalf("wait = %v, want between %v and %v", wait, minWait, maxWait)
+			}
+		})
+	}
+}
+
+func TestBreakerStateTripsAtThreshold(t *testing.T) {
+	ilog := logger.Log{ModuleName: logger.Framework, User: "test", ControllerName: "supervisor_test"}
+	s := &breakerState{backoff: supervisorBaseBackoff}
+
+	for i := 1; i < supervisorBreakerTripAt; i++ {
+		s.recordFailure("host|queue|selector", ilog, errTestFailure)
+		if s.breakerOpen() {
+			t.Fatalf("breaker opened after %d failures, want it closed until %d", i, supervisorBreakerTripAt)
+		}
+	}
+
+	s.recordFailure("host|queue|selector", ilog, errTestFailure)
+	if !s.breakerOpen() {
+		t.Fatalf("breaker closed after %d consecutive failures, want it open", supervisorBreakerTripAt)
+	}
+}
+
+func TestBreakerStateRecordSuccessResets(t *testing.T) {
+	ilog := logger.Log{ModuleName: logger.Framework, User: "test", ControllerName: "supervisor_test"}
+	s := &breakerState{backoff: supervisorBaseBackoff}
+
+	for i := 0; i < supervisorBreakerTripAt; i++ {
+		s.recordFailure("host|queue|selector", ilog, errTestFailure)
+	}
+	if !s.breakerOpen() {
+		t.Fatalf("expected breaker to be open before recordSuccess")
+	}
+
+	s.recordSuccess()
+
+	if s.breakerOpen() {
+		t.Fatalf("breaker still open after recordSuccess")
+	}
+	if s.consecutiveFailures != 0 {
+		t.Fatalf("consecutiveFailures = %d, want 0", s.consecutiveFailures)
+	}
+	if s.backoff != supervisorBaseBackoff {
+		t.Fatalf("backoff = %v, want reset to base %v", s.backoff, supervisorBaseBackoff)
+	}
+	if !s.nextAttempt.IsZero() {
+		t.Fatalf("nextAttempt = %v, want zero value after reset", s.nextAttempt)
+	}
+}
+
+func TestConnectionKeyHost(t *testing.T) {
+	cases := []struct {
+		key  string
+		want string
+	}{
+		{"broker.local:61613|orders|", "broker.local:61613"},
+		{"broker.local:61613|orders|type = 'order'", "broker.local:61613"},
+		{"broker.local:61613", "broker.local:61613"},
+	}
+
+	for _, c := range cases {
+		if got := connectionKeyHost(c.key); got != c.want {
+			t.Errorf("connectionKeyHost(%q) = %q, want %q", c.key, got, c.want)
+		}
+	}
+}
+
+var errTestFailure = fakeError("simulated subscribe failure")
+
+type fakeError string
+
+func (e fakeError) Error() string { return string(e) }
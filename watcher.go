@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mdaxf/iac/integration/activemq"
+)
+
+// activemqConfigDebounce collapses the burst of fsnotify events a single save
+// typically produces (write + chmod + rename on some editors/filesystems) into
+// one reload.
+const activemqConfigDebounce = 500 * time.Millisecond
+
+// Watch watches activemqconfig.json for changes and calls onChange with the freshly
+// reloaded configuration whenever it is modified, so edits take effect without an
+// operator having to call the /reloadconfig endpoint.
+func (f fileConfigSource) Watch(ctx context.Context, onChange func(activemq.ActiveMQconfigs)) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		f.ilog.Error(fmt.Sprintf("failed to start ActiveMQ config file watcher: %v", err))
+		return
+	}
+	defer watcher.Close()
+
+	// Watch the containing directory rather than f.path itself: editors and tools that
+	// save atomically (write a temp file, then rename it over the target) or ConfigMap
+	// symlink swaps replace the underlying inode, which frequently invalidates an
+	// inotify watch on the file on Linux - the watcher would then stop firing after the
+	// very first edit with nothing logged. Watching the directory survives that, at the
+	// cost of having to filter events down to this file's basename ourselves.
+	configDir := filepath.Dir(f.path)
+	configName := filepath.Base(f.path)
+	if err := watcher.Add(configDir); err != nil {
+		f.ilog.Error(fmt.Sprintf("failed to watch %s: %v", configDir, err))
+		return
+	}
+	f.ilog.Debug(fmt.Sprintf("watching %s for changes to %s", configDir, configName))
+
+	var debounce *time.Timer
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Base(event.Name) != configName {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if debounce == nil {
+				debounce = time.AfterFunc(activemqConfigDebounce, func() {
+					f.ilog.Debug(fmt.Sprintf("%s changed, reloading ActiveMQ connections", f.path))
+					cfgs, err := f.Load()
+					if err != nil {
+						f.ilog.Error(fmt.Sprintf("failed to reload %s: %v", f.path, err))
+						return
+					}
+					onChange(cfgs)
+				})
+			} else {
+				debounce.Reset(activemqConfigDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			f.ilog.Error(fmt.Sprintf("ActiveMQ config file watcher error: %v", err))
+		}
+	}
+}